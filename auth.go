@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// htpasswdStore holds username -> bcrypt hash pairs loaded from an
+// htpasswd-style file (one "user:hash" pair per line, '#' starts a
+// comment). Only the bcrypt ($2a$/$2b$/$2y$) hash format is supported.
+type htpasswdStore struct {
+	mu    sync.RWMutex
+	creds map[string]string
+}
+
+func loadHtpasswd(path string) (*htpasswdStore, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	store := &htpasswdStore{creds: make(map[string]string)}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		store.creds[parts[0]] = parts[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *htpasswdStore) verify(user, pass string) bool {
+	s.mu.RLock()
+	hash, existed := s.creds[user]
+	s.mu.RUnlock()
+	if !existed {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(pass)) == nil
+}
+
+// authGuard wraps mutation handlers (load/unload) so that remote callers
+// must either present a bearer token matching -auth-token or valid
+// htpasswd credentials via HTTP Basic auth. Requests from loopback
+// addresses are trusted as before and bypass the check entirely.
+type authGuard struct {
+	token    string
+	htpasswd *htpasswdStore
+}
+
+func newAuthGuard(token, htpasswdPath string) (*authGuard, error) {
+	guard := &authGuard{token: token}
+	if htpasswdPath != "" {
+		store, err := loadHtpasswd(htpasswdPath)
+		if err != nil {
+			return nil, err
+		}
+		guard.htpasswd = store
+	}
+	return guard, nil
+}
+
+func (g *authGuard) authenticated(r *http.Request) bool {
+	return g.authenticatedHeader(r.Header)
+}
+
+// authenticatedHeader checks the same bearer-token/htpasswd credentials
+// as authenticated, but off a bare http.Header so non-HTTP transports
+// (e.g. gRPC metadata) can reuse the same guard.
+func (g *authGuard) authenticatedHeader(header http.Header) bool {
+	auth := header.Get("Authorization")
+	if g.token != "" && auth == "Bearer "+g.token {
+		return true
+	}
+	if g.htpasswd != nil {
+		if user, pass, ok := parseBasicAuth(auth); ok && g.htpasswd.verify(user, pass) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseBasicAuth decodes the value of an HTTP Authorization header in
+// the "Basic base64(user:pass)" form, mirroring what http.Request.
+// BasicAuth does for net/http requests.
+func parseBasicAuth(auth string) (user, pass string, ok bool) {
+	const prefix = "Basic "
+	if !strings.HasPrefix(auth, prefix) {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(auth[len(prefix):])
+	if err != nil {
+		return "", "", false
+	}
+	user, pass, ok = strings.Cut(string(decoded), ":")
+	return user, pass, ok
+}
+
+func (g *authGuard) wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if isLocal(r.RemoteAddr) || g.authenticated(r) {
+			next(w, r)
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="pomelo"`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	}
+}
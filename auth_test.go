@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAuthGuardBearerToken(t *testing.T) {
+	guard, err := newAuthGuard("secret-token", "")
+	if err != nil {
+		t.Fatalf("newAuthGuard: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/index/", nil)
+	if guard.authenticated(req) {
+		t.Fatalf("authenticated with no Authorization header: got true, want false")
+	}
+
+	req.Header.Set("Authorization", "Bearer secret-token")
+	if !guard.authenticated(req) {
+		t.Fatalf("authenticated with the correct bearer token: got false, want true")
+	}
+
+	req.Header.Set("Authorization", "Bearer wrong-token")
+	if guard.authenticated(req) {
+		t.Fatalf("authenticated with the wrong bearer token: got true, want false")
+	}
+}
+
+func TestAuthGuardHtpasswd(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt.GenerateFromPassword: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	if err := os.WriteFile(path, []byte("alice:"+string(hash)+"\n"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	guard, err := newAuthGuard("", path)
+	if err != nil {
+		t.Fatalf("newAuthGuard: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/index/", nil)
+	req.SetBasicAuth("alice", "hunter2")
+	if !guard.authenticated(req) {
+		t.Fatalf("authenticated with correct htpasswd credentials: got false, want true")
+	}
+
+	req.SetBasicAuth("alice", "wrong-password")
+	if guard.authenticated(req) {
+		t.Fatalf("authenticated with wrong htpasswd password: got true, want false")
+	}
+
+	req.SetBasicAuth("mallory", "hunter2")
+	if guard.authenticated(req) {
+		t.Fatalf("authenticated with unknown htpasswd user: got true, want false")
+	}
+}
+
+func TestAuthGuardWrapAllowsLoopbackWithoutCredentials(t *testing.T) {
+	guard, err := newAuthGuard("secret-token", "")
+	if err != nil {
+		t.Fatalf("newAuthGuard: %v", err)
+	}
+
+	called := false
+	handler := guard.wrap(func(w http.ResponseWriter, r *http.Request) { called = true })
+
+	req := httptest.NewRequest(http.MethodPost, "/index/", nil)
+	req.RemoteAddr = "127.0.0.1:54321"
+	w := httptest.NewRecorder()
+	handler(w, req)
+
+	if !called || w.Code != http.StatusOK {
+		t.Fatalf("wrap for a loopback request: handler called=%v, status=%v, want called=true, status=200", called, w.Code)
+	}
+}
+
+func TestAuthGuardWrapRejectsRemoteWithoutCredentials(t *testing.T) {
+	guard, err := newAuthGuard("secret-token", "")
+	if err != nil {
+		t.Fatalf("newAuthGuard: %v", err)
+	}
+
+	called := false
+	handler := guard.wrap(func(w http.ResponseWriter, r *http.Request) { called = true })
+
+	req := httptest.NewRequest(http.MethodPost, "/index/", nil)
+	req.RemoteAddr = "203.0.113.9:54321"
+	w := httptest.NewRecorder()
+	handler(w, req)
+
+	if called || w.Code != http.StatusUnauthorized {
+		t.Fatalf("wrap for an unauthenticated remote request: handler called=%v, status=%v, want called=false, status=401", called, w.Code)
+	}
+}
+
+func TestIPRateLimiterAllowsUpToBurstThenBlocks(t *testing.T) {
+	rl := newIPRateLimiter(1, 3)
+
+	for i := 0; i < 3; i++ {
+		if !rl.allow("203.0.113.9") {
+			t.Fatalf("allow() call %v within burst: got false, want true", i+1)
+		}
+	}
+	if rl.allow("203.0.113.9") {
+		t.Fatalf("allow() call beyond burst: got true, want false")
+	}
+}
+
+func TestIPRateLimiterTracksIPsIndependently(t *testing.T) {
+	rl := newIPRateLimiter(1, 1)
+
+	if !rl.allow("203.0.113.9") {
+		t.Fatalf("allow() for 203.0.113.9's first request: got false, want true")
+	}
+	if !rl.allow("198.51.100.1") {
+		t.Fatalf("allow() for a different IP's first request: got false, want true")
+	}
+	if rl.allow("203.0.113.9") {
+		t.Fatalf("allow() for 203.0.113.9's second request within the same burst: got true, want false")
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// lengthPrefixedSlotSize bounds the json/msgpack codecs to a fixed-width
+// slot (a uvarint length header followed by the payload, zero-padded),
+// so entries keep the fixed-width value segment indexLookup relies on to
+// locate a match's boundaries without scanning the whole buffer.
+const lengthPrefixedSlotSize = 256
+
+// ValueCodec encodes the per-entry value segment written after an
+// index's text column and decodes it back into named fields, so an
+// index can carry more than the original single uvarint weight.
+type ValueCodec interface {
+	Encode(fields ...interface{}) []byte
+	Decode(data []byte) (map[string]interface{}, error)
+	Size() int
+	// Columns returns the field names this codec's Encode/Decode expect,
+	// in the order buildIndex reads them from a row's tab-separated
+	// columns.
+	Columns() []string
+}
+
+// valueColumn picks the column that doubles as an index entry's weight:
+// used to satisfy -min-value during a build and to populate Item.Value/
+// the ranking score during a query. A column literally named "weight"
+// or "value" wins regardless of position, since that's the name callers
+// use for it in schemas like "json:id,weight"; otherwise the first
+// declared column is used, matching the original single-weight codec.
+func valueColumn(columns []string) string {
+	for _, column := range columns {
+		if column == "weight" || column == "value" {
+			return column
+		}
+	}
+	if len(columns) > 0 {
+		return columns[0]
+	}
+	return ""
+}
+
+// parseSchema turns a -schema flag value of the form
+// "<codec>[:col1,col2,...]" into a ValueCodec. An empty schema defaults
+// to "uvarint:value", matching the original single-weight behavior.
+func parseSchema(schema string) (ValueCodec, error) {
+	if schema == "" {
+		schema = "uvarint:value"
+	}
+
+	parts := strings.SplitN(schema, ":", 2)
+	name := parts[0]
+	var columns []string
+	if len(parts) == 2 && parts[1] != "" {
+		columns = strings.Split(parts[1], ",")
+	}
+
+	switch name {
+	case "uvarint":
+		column := "value"
+		if len(columns) > 0 {
+			column = columns[0]
+		}
+		return &uvarintCodec{column: column}, nil
+	case "fixed64x2":
+		cols := [2]string{"field0", "field1"}
+		for i := 0; i < len(columns) && i < 2; i++ {
+			cols[i] = columns[i]
+		}
+		return &fixed64x2Codec{columns: cols}, nil
+	case "json", "msgpack":
+		return &lengthPrefixedCodec{format: name, columns: columns}, nil
+	default:
+		return nil, fmt.Errorf("pomelo: unknown value codec %q", name)
+	}
+}
+
+func toUint64(field interface{}) uint64 {
+	switch v := field.(type) {
+	case uint64:
+		return v
+	case string:
+		val, _ := strconv.ParseUint(v, 10, 64)
+		return val
+	default:
+		return 0
+	}
+}
+
+// uvarintCodec is the original behavior: a single uvarint weight,
+// written into a fixed binary.MaxVarintLen64-byte slot.
+type uvarintCodec struct {
+	column string
+}
+
+func (c *uvarintCodec) Size() int { return binary.MaxVarintLen64 }
+
+func (c *uvarintCodec) Columns() []string { return []string{c.column} }
+
+func (c *uvarintCodec) Encode(fields ...interface{}) []byte {
+	var val uint64
+	if len(fields) > 0 {
+		val = toUint64(fields[0])
+	}
+	buf := make([]byte, binary.MaxVarintLen64)
+	binary.PutUvarint(buf, val)
+	return buf
+}
+
+func (c *uvarintCodec) Decode(data []byte) (map[string]interface{}, error) {
+	val, _ := binary.Uvarint(data)
+	return map[string]interface{}{c.column: val}, nil
+}
+
+// fixed64x2Codec stores two uint64 fields, e.g. {weight, category}, each
+// big-endian in its own 8-byte slot.
+type fixed64x2Codec struct {
+	columns [2]string
+}
+
+func (c *fixed64x2Codec) Size() int { return 16 }
+
+func (c *fixed64x2Codec) Columns() []string { return c.columns[:] }
+
+func (c *fixed64x2Codec) Encode(fields ...interface{}) []byte {
+	buf := make([]byte, 16)
+	if len(fields) > 0 {
+		binary.BigEndian.PutUint64(buf[0:8], toUint64(fields[0]))
+	}
+	if len(fields) > 1 {
+		binary.BigEndian.PutUint64(buf[8:16], toUint64(fields[1]))
+	}
+	return buf
+}
+
+func (c *fixed64x2Codec) Decode(data []byte) (map[string]interface{}, error) {
+	if len(data) < 16 {
+		return nil, fmt.Errorf("pomelo: fixed64x2 value segment too short: %v bytes", len(data))
+	}
+	return map[string]interface{}{
+		c.columns[0]: binary.BigEndian.Uint64(data[0:8]),
+		c.columns[1]: binary.BigEndian.Uint64(data[8:16]),
+	}, nil
+}
+
+// lengthPrefixedCodec serializes the named columns as a JSON or MsgPack
+// object into a fixed-size slot: a uvarint length header, the payload,
+// then zero padding. This unblocks richer per-entry payloads (e.g. a
+// string id alongside numeric fields) at the cost of a size cap.
+type lengthPrefixedCodec struct {
+	format  string // "json" or "msgpack"
+	columns []string
+}
+
+func (c *lengthPrefixedCodec) Size() int { return lengthPrefixedSlotSize }
+
+func (c *lengthPrefixedCodec) Columns() []string { return c.columns }
+
+func (c *lengthPrefixedCodec) marshal(values map[string]interface{}) ([]byte, error) {
+	if c.format == "msgpack" {
+		return msgpack.Marshal(values)
+	}
+	return json.Marshal(values)
+}
+
+func (c *lengthPrefixedCodec) unmarshal(data []byte, values *map[string]interface{}) error {
+	if c.format == "msgpack" {
+		return msgpack.Unmarshal(data, values)
+	}
+	return json.Unmarshal(data, values)
+}
+
+func (c *lengthPrefixedCodec) Encode(fields ...interface{}) []byte {
+	values := make(map[string]interface{}, len(c.columns))
+	for i, column := range c.columns {
+		if i < len(fields) {
+			values[column] = fields[i]
+		}
+	}
+
+	payload, err := c.marshal(values)
+	if err != nil {
+		payload = nil
+	}
+
+	// Reserve the header's own worst-case size up front so the length we
+	// write below matches the payload bytes actually copied into the
+	// slot; otherwise Decode reads back a length longer than what's
+	// there and rejects the entry as corrupt.
+	maxPayload := lengthPrefixedSlotSize - binary.MaxVarintLen64
+	if len(payload) > maxPayload {
+		payload = payload[:maxPayload] // truncate silently if it doesn't fit the fixed slot
+	}
+
+	header := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(header, uint64(len(payload)))
+
+	slot := make([]byte, lengthPrefixedSlotSize)
+	copy(slot, header[:n])
+	copy(slot[n:], payload)
+	return slot
+}
+
+func (c *lengthPrefixedCodec) Decode(data []byte) (map[string]interface{}, error) {
+	length, n := binary.Uvarint(data)
+	if n <= 0 || int(length) > len(data)-n {
+		return nil, fmt.Errorf("pomelo: corrupt length-prefixed value segment")
+	}
+
+	values := make(map[string]interface{})
+	if err := c.unmarshal(data[n:n+int(length)], &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
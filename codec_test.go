@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func TestParseSchema(t *testing.T) {
+	cases := []struct {
+		schema string
+		name   string
+		size   int
+	}{
+		{"", "uvarint", binary.MaxVarintLen64},
+		{"uvarint:weight", "uvarint", binary.MaxVarintLen64},
+		{"fixed64x2:weight,category", "fixed64x2", 16},
+		{"json:id,weight", "json", lengthPrefixedSlotSize},
+		{"msgpack:id,weight", "msgpack", lengthPrefixedSlotSize},
+	}
+	for _, c := range cases {
+		codec, err := parseSchema(c.schema)
+		if err != nil {
+			t.Fatalf("parseSchema(%q): %v", c.schema, err)
+		}
+		if codec.Size() != c.size {
+			t.Fatalf("parseSchema(%q).Size(): got %v, want %v", c.schema, codec.Size(), c.size)
+		}
+	}
+
+	if _, err := parseSchema("unknown"); err == nil {
+		t.Fatalf("parseSchema(%q): expected an error, got nil", "unknown")
+	}
+}
+
+func TestValueColumn(t *testing.T) {
+	cases := []struct {
+		columns []string
+		want    string
+	}{
+		{[]string{"value"}, "value"},
+		{[]string{"weight"}, "weight"},
+		{[]string{"id", "weight"}, "weight"},
+		{[]string{"weight", "category"}, "weight"},
+		{[]string{"id", "category"}, "id"},
+		{nil, ""},
+	}
+	for _, c := range cases {
+		if got := valueColumn(c.columns); got != c.want {
+			t.Errorf("valueColumn(%v): got %q, want %q", c.columns, got, c.want)
+		}
+	}
+}
+
+func TestUvarintCodecRoundTrip(t *testing.T) {
+	codec, err := parseSchema("uvarint:weight")
+	if err != nil {
+		t.Fatalf("parseSchema: %v", err)
+	}
+
+	encoded := codec.Encode(uint64(42))
+	if len(encoded) != codec.Size() {
+		t.Fatalf("Encode: got %v bytes, want %v", len(encoded), codec.Size())
+	}
+
+	fields, err := codec.Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if fields["weight"] != uint64(42) {
+		t.Fatalf("Decode: got %v, want weight=42", fields)
+	}
+}
+
+func TestFixed64x2CodecRoundTrip(t *testing.T) {
+	codec, err := parseSchema("fixed64x2:weight,category")
+	if err != nil {
+		t.Fatalf("parseSchema: %v", err)
+	}
+
+	encoded := codec.Encode(uint64(7), uint64(99))
+	fields, err := codec.Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if fields["weight"] != uint64(7) || fields["category"] != uint64(99) {
+		t.Fatalf("Decode: got %v, want weight=7 category=99", fields)
+	}
+}
+
+func TestLengthPrefixedCodecRoundTrip(t *testing.T) {
+	for _, format := range []string{"json", "msgpack"} {
+		codec, err := parseSchema(format + ":id,weight")
+		if err != nil {
+			t.Fatalf("parseSchema(%v): %v", format, err)
+		}
+
+		encoded := codec.Encode("abc123", uint64(5))
+		if len(encoded) != lengthPrefixedSlotSize {
+			t.Fatalf("Encode(%v): got %v bytes, want %v", format, len(encoded), lengthPrefixedSlotSize)
+		}
+
+		fields, err := codec.Decode(encoded)
+		if err != nil {
+			t.Fatalf("Decode(%v): %v", format, err)
+		}
+		if fields["id"] != "abc123" {
+			t.Fatalf("Decode(%v): got id=%v, want abc123", format, fields["id"])
+		}
+	}
+}
+
+func TestLengthPrefixedCodecTruncatesOversizedPayload(t *testing.T) {
+	codec, err := parseSchema("json:id")
+	if err != nil {
+		t.Fatalf("parseSchema: %v", err)
+	}
+
+	encoded := codec.Encode(strings.Repeat("x", lengthPrefixedSlotSize*2))
+	if len(encoded) != lengthPrefixedSlotSize {
+		t.Fatalf("Encode: got %v bytes, want %v", len(encoded), lengthPrefixedSlotSize)
+	}
+
+	// The length header written during Encode must match the payload
+	// bytes actually copied into the slot, or Decode reads back a length
+	// longer than what's there and rejects every truncated entry as
+	// corrupt at its length check.
+	length, n := binary.Uvarint(encoded)
+	if n <= 0 {
+		t.Fatalf("Decode: could not read length header back")
+	}
+	maxPayload := lengthPrefixedSlotSize - binary.MaxVarintLen64
+	if int(length) > maxPayload {
+		t.Fatalf("Encode: length header %v exceeds the truncated payload bound %v", length, maxPayload)
+	}
+	if int(length) > len(encoded)-n {
+		t.Fatalf("Encode: length header %v exceeds the bytes actually present (%v)", length, len(encoded)-n)
+	}
+}
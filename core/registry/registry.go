@@ -0,0 +1,275 @@
+// Package registry manages the set of loaded suffix-array indexes,
+// replacing a bare in-memory map with one that persists a manifest to
+// disk (so a restart doesn't require re-issuing load calls) and watches
+// each source file for changes so an index can be reloaded in place.
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"index/suffixarray"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Entry is the manifest record persisted for each loaded index.
+type Entry struct {
+	Key      string    `json:"key"`
+	Path     string    `json:"path"`
+	SHA256   string    `json:"sha256"`
+	Schema   string    `json:"schema,omitempty"`
+	LoadedAt time.Time `json:"loadedAt"`
+}
+
+type item struct {
+	index    *suffixarray.Index
+	path     string
+	sha256   string
+	schema   string
+	loadedAt time.Time
+	reload   func() (*suffixarray.Index, error)
+}
+
+// Registry guards the loaded indexes with a sync.RWMutex so concurrent
+// query reads and load/unload/reload writes no longer race, and mirrors
+// every change to a JSON manifest file on disk.
+type Registry struct {
+	mu           sync.RWMutex
+	items        map[string]*item
+	manifestPath string
+	watcher      *fsnotify.Watcher
+	logger       *log.Logger
+}
+
+// New creates a Registry backed by manifestPath and starts its fsnotify
+// watch loop. Call Close when done to release the watcher.
+func New(manifestPath string, logger *log.Logger) (*Registry, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Registry{
+		items:        make(map[string]*item),
+		manifestPath: manifestPath,
+		watcher:      watcher,
+		logger:       logger,
+	}
+	go r.watchLoop()
+	return r, nil
+}
+
+func (r *Registry) watchLoop() {
+	for {
+		select {
+		case event, ok := <-r.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			r.handleFileChange(event.Name)
+		case err, ok := <-r.watcher.Errors:
+			if !ok {
+				return
+			}
+			r.logf("watcher error: %v", err)
+		}
+	}
+}
+
+func (r *Registry) handleFileChange(path string) {
+	r.mu.RLock()
+	var key string
+	var target *item
+	for k, it := range r.items {
+		if it.path == path {
+			key, target = k, it
+			break
+		}
+	}
+	r.mu.RUnlock()
+
+	if target == nil || target.reload == nil {
+		return
+	}
+
+	index, err := target.reload()
+	if err != nil {
+		r.logf("failed to reload index [%v]: %v", key, err)
+		return
+	}
+
+	r.mu.Lock()
+	target.index = index
+	target.loadedAt = time.Now()
+	r.mu.Unlock()
+
+	if err := r.saveManifest(); err != nil {
+		r.logf("failed to persist manifest after reloading [%v]: %v", key, err)
+	}
+}
+
+func (r *Registry) logf(format string, args ...interface{}) {
+	if r.logger != nil {
+		r.logger.Printf("[registry] "+format, args...)
+	}
+}
+
+// Put registers or replaces the index stored under key, watches path for
+// changes, and persists the manifest. reload is invoked by the watcher
+// to rebuild the index when path changes on disk; it may be nil if the
+// caller doesn't want hot-reload (e.g. no source path to watch). schema
+// records the ValueCodec descriptor the index's entries were built
+// with, so callers can look it up again at query time.
+func (r *Registry) Put(key, path, sha256sum, schema string, index *suffixarray.Index, reload func() (*suffixarray.Index, error)) error {
+	r.mu.Lock()
+	if existing, ok := r.items[key]; ok && existing.path != "" && existing.path != path {
+		r.watcher.Remove(existing.path)
+	}
+	r.items[key] = &item{
+		index:    index,
+		path:     path,
+		sha256:   sha256sum,
+		schema:   schema,
+		loadedAt: time.Now(),
+		reload:   reload,
+	}
+	r.mu.Unlock()
+
+	if path != "" {
+		if err := r.watcher.Add(path); err != nil {
+			r.logf("could not watch %v: %v", path, err)
+		}
+	}
+	return r.saveManifest()
+}
+
+// Get returns the index stored under key, if any.
+func (r *Registry) Get(key string) (*suffixarray.Index, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	it, ok := r.items[key]
+	if !ok {
+		return nil, false
+	}
+	return it.index, true
+}
+
+// Schema returns the ValueCodec descriptor the index stored under key
+// was built with, if any.
+func (r *Registry) Schema(key string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	it, ok := r.items[key]
+	if !ok {
+		return "", false
+	}
+	return it.schema, true
+}
+
+// Delete removes key from the registry and stops watching its file.
+func (r *Registry) Delete(key string) {
+	r.mu.Lock()
+	it, ok := r.items[key]
+	if ok {
+		delete(r.items, key)
+		if it.path != "" {
+			r.watcher.Remove(it.path)
+		}
+	}
+	r.mu.Unlock()
+
+	if ok {
+		if err := r.saveManifest(); err != nil {
+			r.logf("failed to persist manifest after deleting [%v]: %v", key, err)
+		}
+	}
+}
+
+// Keys returns the set of currently loaded index keys.
+func (r *Registry) Keys() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	keys := make([]string, 0, len(r.items))
+	for k := range r.items {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Reload rebuilds the index stored under key using the reload function
+// supplied to Put, e.g. in response to a manual /reload request.
+func (r *Registry) Reload(key string) error {
+	r.mu.RLock()
+	it, ok := r.items[key]
+	r.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("registry: index [%v] not found", key)
+	}
+	if it.reload == nil {
+		return fmt.Errorf("registry: index [%v] has no known source to reload from", key)
+	}
+
+	index, err := it.reload()
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	it.index = index
+	it.loadedAt = time.Now()
+	r.mu.Unlock()
+
+	return r.saveManifest()
+}
+
+// Manifest returns a snapshot of every entry currently tracked.
+func (r *Registry) Manifest() []Entry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entries := make([]Entry, 0, len(r.items))
+	for key, it := range r.items {
+		entries = append(entries, Entry{Key: key, Path: it.path, SHA256: it.sha256, Schema: it.schema, LoadedAt: it.loadedAt})
+	}
+	return entries
+}
+
+func (r *Registry) saveManifest() error {
+	if r.manifestPath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(r.Manifest(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.manifestPath, data, 0644)
+}
+
+// LoadManifestEntries reads a previously persisted manifest file. A
+// missing file is not an error; it just yields no entries.
+func LoadManifestEntries(manifestPath string) ([]Entry, error) {
+	data, err := os.ReadFile(manifestPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Close stops the file watcher.
+func (r *Registry) Close() error {
+	return r.watcher.Close()
+}
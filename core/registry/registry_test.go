@@ -0,0 +1,116 @@
+package registry
+
+import (
+	"index/suffixarray"
+	"io"
+	"log"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func newTestRegistry(t *testing.T) *Registry {
+	t.Helper()
+	manifestPath := filepath.Join(t.TempDir(), "manifest.json")
+	r, err := New(manifestPath, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func TestRegistryPutGetDelete(t *testing.T) {
+	r := newTestRegistry(t)
+	index := suffixarray.New([]byte("hello world"))
+
+	if err := r.Put("k1", "", "sha", "uvarint:value", index, nil); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	got, existed := r.Get("k1")
+	if !existed || got != index {
+		t.Fatalf("Get: got (%v, %v), want the stored index and true", got, existed)
+	}
+
+	schema, existed := r.Schema("k1")
+	if !existed || schema != "uvarint:value" {
+		t.Fatalf("Schema: got (%v, %v), want (uvarint:value, true)", schema, existed)
+	}
+
+	r.Delete("k1")
+	if _, existed := r.Get("k1"); existed {
+		t.Fatalf("Get after Delete: key still present")
+	}
+}
+
+func TestRegistryGetMissingKey(t *testing.T) {
+	r := newTestRegistry(t)
+	if index, existed := r.Get("missing"); existed || index != nil {
+		t.Fatalf("Get(missing): got (%v, %v), want (nil, false)", index, existed)
+	}
+}
+
+// TestRegistryConcurrentGetAndDelete exercises the race the chunk0-5
+// sync.RWMutex guards against: one goroutine repeatedly deletes and
+// reloads a key while others read it. It only proves absence of a data
+// race (run with -race); callers are still responsible for treating a
+// (nil, false) Get result as "gone", which is what queryHandler does.
+func TestRegistryConcurrentGetAndDelete(t *testing.T) {
+	r := newTestRegistry(t)
+	index := suffixarray.New([]byte("hello world"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				r.Get("k1")
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 100; j++ {
+			r.Put("k1", "", "sha", "uvarint:value", index, nil)
+			r.Delete("k1")
+		}
+	}()
+
+	wg.Wait()
+}
+
+func TestRegistryManifestRoundTrip(t *testing.T) {
+	manifestPath := filepath.Join(t.TempDir(), "manifest.json")
+	r, err := New(manifestPath, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer r.Close()
+
+	index := suffixarray.New([]byte("hello world"))
+	if err := r.Put("k1", "/tmp/does-not-need-to-exist.idx", "sha", "json:id", index, nil); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	entries, err := LoadManifestEntries(manifestPath)
+	if err != nil {
+		t.Fatalf("LoadManifestEntries: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Key != "k1" || entries[0].Schema != "json:id" {
+		t.Fatalf("LoadManifestEntries: got %+v, want one entry for k1 with schema json:id", entries)
+	}
+}
+
+func TestLoadManifestEntriesMissingFileIsNotAnError(t *testing.T) {
+	entries, err := LoadManifestEntries(filepath.Join(t.TempDir(), "does-not-exist.json"))
+	if err != nil {
+		t.Fatalf("LoadManifestEntries with missing file: %v", err)
+	}
+	if entries != nil {
+		t.Fatalf("LoadManifestEntries with missing file: got %v, want nil", entries)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	saltSize         = 16
+	ivSize           = aes.BlockSize
+	macSize          = sha256.Size
+	decryptChunkSize = 1 << 20 // 1 MiB, keeps decryptFile from doubling memory on large indexes
+)
+
+var errIntegrityCheck = errors.New("pomelo: index file failed integrity check, wrong key or corrupted data")
+
+// deriveKeys turns a passphrase plus a random salt into an AES-256 key
+// and a separate HMAC-SHA256 key via scrypt, so the same secret isn't
+// reused for both encryption and authentication.
+func deriveKeys(passphrase string, salt []byte) (encKey, macKey []byte, err error) {
+	derived, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 64)
+	if err != nil {
+		return nil, nil, err
+	}
+	return derived[:32], derived[32:], nil
+}
+
+// encryptWriter wraps an index destination file with AES-CTR encryption
+// and appends an HMAC-SHA256 trailer over the salt, IV and ciphertext so
+// tampering or a wrong key is detected on load.
+type encryptWriter struct {
+	dst    io.Writer
+	stream cipher.Stream
+	mac    hash.Hash
+}
+
+func newEncryptWriter(dst io.Writer, passphrase string) (*encryptWriter, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	iv := make([]byte, ivSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	encKey, macKey, err := deriveKeys(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := dst.Write(salt); err != nil {
+		return nil, err
+	}
+	if _, err := dst.Write(iv); err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(salt)
+	mac.Write(iv)
+
+	return &encryptWriter{
+		dst:    dst,
+		stream: cipher.NewCTR(block, iv),
+		mac:    mac,
+	}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	ciphertext := make([]byte, len(p))
+	e.stream.XORKeyStream(ciphertext, p)
+	e.mac.Write(ciphertext)
+	return e.dst.Write(ciphertext)
+}
+
+// Close writes the HMAC trailer. It does not close the underlying dst.
+func (e *encryptWriter) Close() error {
+	_, err := e.dst.Write(e.mac.Sum(nil))
+	return err
+}
+
+// decryptFile reads an index file produced by encryptWriter back into an
+// in-memory plaintext buffer, verifying the HMAC trailer as it streams
+// the ciphertext body through the CTR cipher in decryptChunkSize chunks.
+func decryptFile(file *os.File, passphrase string) ([]byte, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	bodySize := info.Size() - saltSize - ivSize - macSize
+	if bodySize < 0 {
+		return nil, errIntegrityCheck
+	}
+
+	header := make([]byte, saltSize+ivSize)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return nil, err
+	}
+	salt, iv := header[:saltSize], header[saltSize:]
+
+	encKey, macKey, err := deriveKeys(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+	stream := cipher.NewCTR(block, iv)
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(salt)
+	mac.Write(iv)
+
+	plaintext := bytes.NewBuffer(make([]byte, 0, bodySize))
+	chunk := make([]byte, decryptChunkSize)
+	remaining := bodySize
+	for remaining > 0 {
+		n := int64(len(chunk))
+		if remaining < n {
+			n = remaining
+		}
+		if _, err := io.ReadFull(file, chunk[:n]); err != nil {
+			return nil, err
+		}
+		mac.Write(chunk[:n])
+		stream.XORKeyStream(chunk[:n], chunk[:n])
+		plaintext.Write(chunk[:n])
+		remaining -= n
+	}
+
+	trailer := make([]byte, macSize)
+	if _, err := io.ReadFull(file, trailer); err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(mac.Sum(nil), trailer) {
+		return nil, errIntegrityCheck
+	}
+
+	return plaintext.Bytes(), nil
+}
+
+// loadDecryptKeys reads a JSON {"indexKey": "passphrase"} file so
+// encrypted indexes can be auto-reloaded from the manifest on startup
+// without their passphrase ever touching the manifest itself. An empty
+// path is not an error; it just yields no keys.
+func loadDecryptKeys(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]string)
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// sha256File hashes the file at path, used to populate the registry
+// manifest's integrity checksum and, when no explicit key is given, to
+// derive a stable default index key.
+func sha256File(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
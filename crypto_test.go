@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	plaintext := []byte("the quick brown fox jumps over the lazy dog\x00more entries here")
+
+	f, err := os.CreateTemp("", "pomelo-crypto-*.idx")
+	if err != nil {
+		t.Fatalf("CreateTemp: %v", err)
+	}
+	defer os.Remove(f.Name())
+
+	ew, err := newEncryptWriter(f, "correct horse battery staple")
+	if err != nil {
+		t.Fatalf("newEncryptWriter: %v", err)
+	}
+	if _, err := ew.Write(plaintext); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := ew.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close file: %v", err)
+	}
+
+	rf, err := os.Open(f.Name())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer rf.Close()
+
+	got, err := decryptFile(rf, "correct horse battery staple")
+	if err != nil {
+		t.Fatalf("decryptFile: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatalf("decryptFile round-trip mismatch: got %q, want %q", got, plaintext)
+	}
+}
+
+func TestDecryptFileWrongPassphraseFailsIntegrityCheck(t *testing.T) {
+	f, err := os.CreateTemp("", "pomelo-crypto-*.idx")
+	if err != nil {
+		t.Fatalf("CreateTemp: %v", err)
+	}
+	defer os.Remove(f.Name())
+
+	ew, err := newEncryptWriter(f, "correct horse battery staple")
+	if err != nil {
+		t.Fatalf("newEncryptWriter: %v", err)
+	}
+	if _, err := ew.Write([]byte("some plaintext")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := ew.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close file: %v", err)
+	}
+
+	rf, err := os.Open(f.Name())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer rf.Close()
+
+	if _, err := decryptFile(rf, "wrong passphrase"); err != errIntegrityCheck {
+		t.Fatalf("decryptFile with wrong passphrase: got err %v, want %v", err, errIntegrityCheck)
+	}
+}
+
+func TestLoadDecryptKeys(t *testing.T) {
+	f, err := os.CreateTemp("", "pomelo-decrypt-keys-*.json")
+	if err != nil {
+		t.Fatalf("CreateTemp: %v", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(`{"index1": "passphrase1", "index2": "passphrase2"}`); err != nil {
+		t.Fatalf("WriteString: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	keys, err := loadDecryptKeys(f.Name())
+	if err != nil {
+		t.Fatalf("loadDecryptKeys: %v", err)
+	}
+	if keys["index1"] != "passphrase1" || keys["index2"] != "passphrase2" {
+		t.Fatalf("loadDecryptKeys: got %v, want index1/index2 passphrases", keys)
+	}
+
+	keys, err = loadDecryptKeys("")
+	if err != nil {
+		t.Fatalf("loadDecryptKeys with empty path: %v", err)
+	}
+	if keys != nil {
+		t.Fatalf("loadDecryptKeys with empty path: got %v, want nil", keys)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/codeb2cc/pomelo/rpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// grpcMutationMethods are the RPCs that load/unload indexes and thus get
+// the same authGuard protection as the REST load/unload endpoints in
+// web.go; every other RPC (Lookup, BatchLookup, ListIndexes) stays open
+// to any client that can reach the port, matching their REST equivalents.
+var grpcMutationMethods = map[string]bool{
+	"/rpc.Pomelo/LoadIndex":   true,
+	"/rpc.Pomelo/UnloadIndex": true,
+}
+
+// grpcQueryMethods are rate-limited per remote IP, matching the REST
+// /index/{key}/ query endpoint's limiter.wrap.
+var grpcQueryMethods = map[string]bool{
+	"/rpc.Pomelo/Lookup":      true,
+	"/rpc.Pomelo/BatchLookup": true,
+}
+
+func grpcPeerAddr(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	return p.Addr.String()
+}
+
+func grpcAuthorized(ctx context.Context, guard *authGuard) bool {
+	if isLocal(grpcPeerAddr(ctx)) {
+		return true
+	}
+	header := make(http.Header)
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		header.Set("Authorization", strings.Join(md.Get("authorization"), ""))
+	}
+	return guard.authenticatedHeader(header)
+}
+
+// unaryAuthInterceptor rejects remote, unauthenticated calls to the
+// mutation RPCs and rate-limits the query RPCs, mirroring the protection
+// chunk0-1 added to the REST handlers.
+func unaryAuthInterceptor(guard *authGuard, limiter *ipRateLimiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if grpcMutationMethods[info.FullMethod] && !grpcAuthorized(ctx, guard) {
+			return nil, status.Error(codes.Unauthenticated, "pomelo: bearer token or basic auth required for this RPC")
+		}
+		if grpcQueryMethods[info.FullMethod] && !limiter.allow(grpcPeerAddr(ctx)) {
+			return nil, status.Error(codes.ResourceExhausted, "pomelo: rate limit exceeded")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// streamAuthInterceptor applies the same rate limit as
+// unaryAuthInterceptor to streaming RPCs (BatchLookup).
+func streamAuthInterceptor(limiter *ipRateLimiter) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if grpcQueryMethods[info.FullMethod] && !limiter.allow(grpcPeerAddr(ss.Context())) {
+			return status.Error(codes.ResourceExhausted, "pomelo: rate limit exceeded")
+		}
+		return handler(srv, ss)
+	}
+}
+
+// pomeloGRPCServer implements rpc.PomeloServer on top of the same
+// indexRegistry the REST handlers in web.go use, so the two APIs always
+// see the same set of loaded indexes.
+type pomeloGRPCServer struct{}
+
+// codecFor looks up the ValueCodec an index was loaded with, falling
+// back to the default uvarint codec if the registry has no record of it
+// (e.g. the key doesn't exist; the caller is expected to have already
+// checked that).
+func codecFor(key string) ValueCodec {
+	schema, _ := indexRegistry.Schema(key)
+	codec, err := parseSchema(schema)
+	if err != nil {
+		codec, _ = parseSchema("")
+	}
+	return codec
+}
+
+func toItemPB(item Item) *rpc.Item {
+	fields := make(map[string]string, len(item.Fields))
+	for k, v := range item.Fields {
+		fields[k] = fmt.Sprint(v)
+	}
+	return &rpc.Item{Query: item.Query, Value: item.Value, Fields: fields}
+}
+
+func (s *pomeloGRPCServer) Lookup(ctx context.Context, req *rpc.LookupRequest) (*rpc.LookupResponse, error) {
+	index, existed := indexRegistry.Get(req.Key)
+	if !existed {
+		return nil, fmt.Errorf("index [%v] not found", req.Key)
+	}
+
+	items := indexLookup(ctx, index, codecFor(req.Key), req.Query, lookupOptions{})
+	resp := &rpc.LookupResponse{Items: make([]*rpc.Item, len(items))}
+	for i, item := range items {
+		resp.Items[i] = toItemPB(item)
+	}
+	return resp, nil
+}
+
+func (s *pomeloGRPCServer) BatchLookup(req *rpc.BatchLookupRequest, stream rpc.Pomelo_BatchLookupServer) error {
+	index, existed := indexRegistry.Get(req.Key)
+	if !existed {
+		return fmt.Errorf("index [%v] not found", req.Key)
+	}
+	codec := codecFor(req.Key)
+
+	ctx := stream.Context()
+	var group sync.WaitGroup
+	ch := make(chan []Item)
+	for _, query := range req.Queries {
+		group.Add(1)
+		go func(q string) {
+			defer group.Done()
+			items := indexLookup(ctx, index, codec, q, lookupOptions{})
+			select {
+			case ch <- items:
+			case <-ctx.Done():
+			}
+		}(query)
+	}
+	go func() {
+		group.Wait()
+		close(ch)
+	}()
+
+	// 每个子查询一完成就立即推送给客户端，而非等待全部完成后再合并返回
+	for items := range ch {
+		for _, item := range items {
+			if err := stream.Send(toItemPB(item)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *pomeloGRPCServer) LoadIndex(ctx context.Context, req *rpc.LoadIndexRequest) (*rpc.LoadIndexResponse, error) {
+	key, err := loadIndex(req.Path, req.Key, req.DecryptKey, req.Schema)
+	if err != nil {
+		return nil, err
+	}
+	return &rpc.LoadIndexResponse{Key: key}, nil
+}
+
+func (s *pomeloGRPCServer) UnloadIndex(ctx context.Context, req *rpc.UnloadIndexRequest) (*rpc.UnloadIndexResponse, error) {
+	if _, existed := indexRegistry.Get(req.Key); !existed {
+		return nil, fmt.Errorf("index [%v] not found", req.Key)
+	}
+	indexRegistry.Delete(req.Key)
+	return &rpc.UnloadIndexResponse{}, nil
+}
+
+func (s *pomeloGRPCServer) ListIndexes(ctx context.Context, req *rpc.ListIndexesRequest) (*rpc.ListIndexesResponse, error) {
+	return &rpc.ListIndexesResponse{Keys: indexRegistry.Keys()}, nil
+}
+
+// grpcServerConfig mirrors webServerConfig so the gRPC API gets the same
+// transport and access-control protections as the REST API.
+type grpcServerConfig struct {
+	TLSCert      string
+	TLSKey       string
+	AuthToken    string
+	AuthHtpasswd string
+	RateLimit    float64
+	RateBurst    int
+}
+
+func startGRPCServer(address string, cfg grpcServerConfig) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	guard, err := newAuthGuard(cfg.AuthToken, cfg.AuthHtpasswd)
+	if err != nil {
+		return err
+	}
+	limiter := newIPRateLimiter(cfg.RateLimit, cfg.RateBurst)
+
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(unaryAuthInterceptor(guard, limiter)),
+		grpc.StreamInterceptor(streamAuthInterceptor(limiter)),
+	}
+	if cfg.TLSCert != "" || cfg.TLSKey != "" {
+		creds, err := credentials.NewServerTLSFromFile(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	server := grpc.NewServer(opts...)
+	rpc.RegisterPomeloServer(server, &pomeloGRPCServer{})
+	return server.Serve(listener)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codeb2cc/pomelo/core/registry"
+	"github.com/codeb2cc/pomelo/rpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startTestGRPCServer wires up the same interceptors/registration as
+// startGRPCServer but serves over an in-memory bufconn listener instead
+// of a real TCP address, and returns a PomeloClient dialed against it.
+func startTestGRPCServer(t *testing.T, cfg grpcServerConfig) rpc.PomeloClient {
+	t.Helper()
+
+	guard, err := newAuthGuard(cfg.AuthToken, cfg.AuthHtpasswd)
+	if err != nil {
+		t.Fatalf("newAuthGuard: %v", err)
+	}
+	limiter := newIPRateLimiter(1000, 1000)
+
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(unaryAuthInterceptor(guard, limiter)),
+		grpc.StreamInterceptor(streamAuthInterceptor(limiter)),
+	)
+	rpc.RegisterPomeloServer(server, &pomeloGRPCServer{})
+
+	listener := bufconn.Listen(1024 * 1024)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock(),
+	)
+	if err != nil {
+		t.Fatalf("grpc.DialContext: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return rpc.NewPomeloClient(conn)
+}
+
+func withTestGRPCRegistry(t *testing.T) {
+	t.Helper()
+	previous := indexRegistry
+	reg, err := registry.New(filepath.Join(t.TempDir(), "manifest.json"), log.New(os.Stderr, "", 0))
+	if err != nil {
+		t.Fatalf("registry.New: %v", err)
+	}
+	t.Cleanup(func() {
+		reg.Close()
+		indexRegistry = previous
+	})
+	indexRegistry = reg
+}
+
+func TestGRPCLoadLookupUnloadRoundTrip(t *testing.T) {
+	withTestGRPCRegistry(t)
+	client := startTestGRPCServer(t, grpcServerConfig{AuthToken: "test-token"})
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.tsv")
+	dst := filepath.Join(dir, "out.idx")
+	if err := os.WriteFile(src, []byte("hello\t5000\nworld\t100\n"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := buildIndex(src, dst, 120, 0, "", "uvarint:value"); err != nil {
+		t.Fatalf("buildIndex: %v", err)
+	}
+
+	// bufconn peers aren't loopback, so the mutation RPCs below need the
+	// same bearer-token credential a real remote client would send.
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer test-token")
+	loadResp, err := client.LoadIndex(ctx, &rpc.LoadIndexRequest{Path: dst, Schema: "uvarint:value"})
+	if err != nil {
+		t.Fatalf("LoadIndex: %v", err)
+	}
+	key := loadResp.Key
+
+	lookupResp, err := client.Lookup(ctx, &rpc.LookupRequest{Key: key, Query: "hello"})
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	if len(lookupResp.Items) != 1 || lookupResp.Items[0].Value != 5000 {
+		t.Fatalf("Lookup: got %+v, want one item with value 5000", lookupResp.Items)
+	}
+
+	stream, err := client.BatchLookup(ctx, &rpc.BatchLookupRequest{Key: key, Queries: []string{"hello", "world"}})
+	if err != nil {
+		t.Fatalf("BatchLookup: %v", err)
+	}
+	var got []*rpc.Item
+	for {
+		item, err := stream.Recv()
+		if err != nil {
+			break
+		}
+		got = append(got, item)
+	}
+	if len(got) != 2 {
+		t.Fatalf("BatchLookup: got %v items, want 2", len(got))
+	}
+
+	if _, err := client.UnloadIndex(ctx, &rpc.UnloadIndexRequest{Key: key}); err != nil {
+		t.Fatalf("UnloadIndex: %v", err)
+	}
+	if _, existed := indexRegistry.Get(key); existed {
+		t.Fatalf("UnloadIndex: key %v still present in the registry", key)
+	}
+}
+
+func TestGRPCLoadIndexRequiresAuthForRemoteClients(t *testing.T) {
+	withTestGRPCRegistry(t)
+	client := startTestGRPCServer(t, grpcServerConfig{AuthToken: "secret-token"})
+
+	// bufconn dials report a zero-value (non-loopback) peer address, so
+	// this exercises the same "remote, unauthenticated" path a real
+	// network client hitting -grpc=:9090 would.
+	_, err := client.LoadIndex(context.Background(), &rpc.LoadIndexRequest{Path: "/etc/passwd"})
+	if err == nil {
+		t.Fatalf("LoadIndex without credentials: got no error, want Unauthenticated")
+	}
+}
@@ -3,18 +3,17 @@ package main
 import (
 	"bufio"
 	"bytes"
-	"encoding/binary"
+	"context"
 	"flag"
 	"fmt"
-	"hash/crc32"
 	"index/suffixarray"
-	"io"
 	"log"
 	"os"
 	"runtime"
 	"strconv"
 	"time"
 
+	"github.com/codeb2cc/pomelo/core/registry"
 	"golang.org/x/text/unicode/norm"
 )
 
@@ -25,18 +24,41 @@ const (
 )
 
 var (
-	saIndexes map[string]*suffixarray.Index
-	logger    *log.Logger
+	indexRegistry *registry.Registry
+	logger        *log.Logger
 )
 
 type Item struct {
-	Query string
-	Value uint64
+	Query  string
+	Value  uint64
+	Score  float64
+	Fields map[string]interface{} `json:",omitempty"`
 }
 
-func buildIndex(src, dst string, length, value uint) (int, error) {
+// buildIndex reads src and writes a suffix array index to dst, encoding
+// each entry's value segment with the codec named by schema (see
+// parseSchema; an empty schema keeps the original single-uvarint-weight
+// behavior). Rows with more value columns than the codec uses are
+// ignored past what the codec consumes; the min-value filter is applied
+// against the tab-separated column at the position of the codec's
+// "weight"/"value" column (see valueColumn), not positionally, so it
+// still lands on the right field when that column isn't first.
+func buildIndex(src, dst string, length, value uint, encryptKey, schema string) (int, error) {
+	codec, err := parseSchema(schema)
+	if err != nil {
+		return 0, err
+	}
+	filterIndex := 0
+	if columns := codec.Columns(); len(columns) > 0 {
+		for i, column := range columns {
+			if column == valueColumn(columns) {
+				filterIndex = i
+				break
+			}
+		}
+	}
+
 	var srcFile *os.File
-	var err error
 	if src == stdinFlag {
 		srcFile = os.Stdin
 	} else {
@@ -48,30 +70,38 @@ func buildIndex(src, dst string, length, value uint) (int, error) {
 	}
 
 	var buffer bytes.Buffer
-	var val uint64
-	valBuf := make([]byte, binary.MaxVarintLen64)
 	counter := 0
 	scanner := bufio.NewScanner(srcFile)
 	for scanner.Scan() {
 		parts := bytes.SplitN(scanner.Bytes(), []byte("\t"), 2)
+		var text []byte
+		var columns []interface{}
 		if len(parts) == 1 { // 无权数据
-			val = 0
+			text = parts[0]
 		} else if len(parts) == 2 { // 带权数据
-			val, err = strconv.ParseUint(string(parts[1]), 10, 32)
-			if err != nil || val < uint64(value) {
+			text = parts[0]
+			fields := bytes.Split(parts[1], []byte("\t"))
+			if filterIndex >= len(fields) {
+				continue
+			}
+			filterVal, err := strconv.ParseUint(string(fields[filterIndex]), 10, 64)
+			if err != nil || filterVal < uint64(value) {
 				continue
 			}
+			columns = make([]interface{}, len(fields))
+			for i, field := range fields {
+				columns[i] = string(field)
+			}
 		} else { // 数据格式错误
 			continue
 		}
-		if uint(len(parts[0])) > length {
+		if uint(len(text)) > length {
 			continue
 		}
 		counter += 1
 
-		binary.PutUvarint(valBuf, val)
-		buffer.Write(norm.NFC.Bytes(bytes.Trim(parts[0], string(delimiter))))
-		buffer.Write(valBuf)
+		buffer.Write(norm.NFC.Bytes(bytes.Trim(text, string(delimiter))))
+		buffer.Write(codec.Encode(columns...))
 		buffer.Write([]byte(string(delimiter)))
 	}
 	saIndex := suffixarray.New(buffer.Bytes())
@@ -82,55 +112,198 @@ func buildIndex(src, dst string, length, value uint) (int, error) {
 	}
 	defer dstFile.Close()
 
-	return counter, saIndex.Write(dstFile)
+	if encryptKey == "" {
+		return counter, saIndex.Write(dstFile)
+	}
+
+	ew, err := newEncryptWriter(dstFile, encryptKey)
+	if err != nil {
+		return 0, err
+	}
+	if err := saIndex.Write(ew); err != nil {
+		return 0, err
+	}
+	return counter, ew.Close()
 }
 
-func loadIndex(src, key string) (string, error) {
+// readIndexFile opens and decodes the suffix array persisted at src,
+// transparently decrypting it first when decryptKey is set.
+func readIndexFile(src, decryptKey string) (*suffixarray.Index, error) {
 	file, err := os.Open(src)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer file.Close()
 
+	index := suffixarray.New([]byte{})
+	if decryptKey == "" {
+		if err := index.Read(file); err != nil {
+			return nil, err
+		}
+		return index, nil
+	}
+
+	plaintext, err := decryptFile(file, decryptKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := index.Read(bytes.NewReader(plaintext)); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// loadIndex reads the suffix array at src into the shared registry under
+// key (deriving one from the file's sha256 when unset), and registers a
+// watch so the index is rebuilt automatically if src changes on disk.
+// schema names the ValueCodec the index's entries were encoded with (see
+// parseSchema); it is validated here and persisted to the manifest so
+// indexLookup can decode values the same way on every subsequent query.
+func loadIndex(src, key, decryptKey, schema string) (string, error) {
+	if _, err := parseSchema(schema); err != nil {
+		return "", err
+	}
+
+	checksum, err := sha256File(src)
+	if err != nil {
+		return "", err
+	}
 	if key == "" {
-		h := crc32.NewIEEE()
-		io.Copy(h, file)
-		key = fmt.Sprintf("%x", h.Sum32())
+		key = checksum[:8]
 	}
-	saIndexes[key] = suffixarray.New([]byte{})
 
-	file.Seek(0, 0)
-	return key, saIndexes[key].Read(file)
+	index, err := readIndexFile(src, decryptKey)
+	if err != nil {
+		return "", err
+	}
+
+	reload := func() (*suffixarray.Index, error) { return readIndexFile(src, decryptKey) }
+	if err := indexRegistry.Put(key, src, checksum, schema, index, reload); err != nil {
+		return "", err
+	}
+	return key, nil
 }
 
-func indexLookup(index *suffixarray.Index, search string) []Item {
-	offsets := index.Lookup([]byte(search), maxLookup)
+// entrySpan locates one record within the raw indexed buffer: its text
+// and the byte range of its codec-encoded value segment.
+type entrySpan struct {
+	text                 string
+	valueStart, valueEnd int
+}
 
-	items := []Item{}
-	indexData := index.Bytes()
-	for _, offset := range offsets {
-		start, end := offset, offset
-		for ; start > 0 && indexData[start-1] != delimiter; start-- {
+// parseEntries walks the raw suffix-array buffer once and splits it back
+// into the records buildIndex wrote (text, followed by a valueSize-byte
+// value segment, followed by one or more delimiter bytes). Used by fuzzy
+// lookups, which need to compare the query against every indexed term
+// rather than only terms reachable by deleting characters from it. ctx
+// is checked between entries so a short query deadline actually bounds
+// this O(n) walk instead of only the Levenshtein filter that follows it.
+func parseEntries(ctx context.Context, indexData []byte, valueSize int) []entrySpan {
+	var entries []entrySpan
+	i := 0
+	for i < len(indexData) {
+		if ctx.Err() != nil { // client disconnected or deadline fired, stop scanning
+			return entries
 		}
-		for ; end < len(indexData) && indexData[end] != delimiter; end++ {
+		start := i
+		for i < len(indexData) && indexData[i] != delimiter {
+			i++
 		}
-		for ; end+1 < len(indexData) && indexData[end+1] == delimiter; end++ { // consume the rest delimiter bytes
+		for i+1 < len(indexData) && indexData[i+1] == delimiter { // consume the rest delimiter bytes, incl. the value segment's zero padding
+			i++
 		}
-		if end-offset < binary.MaxVarintLen64 { // indexed bytes in the value segment
-			continue
+		if i-start >= valueSize {
+			entries = append(entries, entrySpan{
+				text:       string(indexData[start : i-valueSize]),
+				valueStart: i - valueSize,
+				valueEnd:   i,
+			})
+		}
+		i++
+	}
+	return entries
+}
+
+func indexLookup(ctx context.Context, index *suffixarray.Index, codec ValueCodec, search string, opts lookupOptions) []Item {
+	mode := opts.Mode
+	if mode == "" {
+		mode = modeExact
+	}
+	valueSize := codec.Size()
+	valueField := valueColumn(codec.Columns())
+	indexData := index.Bytes()
+	items := []Item{}
+
+	decodeItem := func(entry string, editDistance int, valueStart, valueEnd int) Item {
+		fields, _ := codec.Decode(indexData[valueStart:valueEnd])
+		// json/msgpack codecs keep the column as the string buildIndex read
+		val := toUint64(fields[valueField])
+		return Item{
+			Query:  entry,
+			Value:  val,
+			Score:  computeScore(mode, editDistance, val, len(entry)),
+			Fields: fields,
+		}
+	}
+
+	if mode == modeFuzzy {
+		// Compare the query against every indexed term directly instead
+		// of only terms reachable by deleting characters from the query:
+		// the latter can never match a term with extra or substituted
+		// characters relative to the query (e.g. query "helo" against
+		// indexed "hello"), since deletions only ever shrink the query.
+		for _, e := range parseEntries(ctx, indexData, valueSize) {
+			if ctx.Err() != nil { // client disconnected or deadline fired, stop doing work nobody will see
+				return items
+			}
+			editDistance := levenshtein(e.text, search)
+			if editDistance > maxFuzzyEdits {
+				continue
+			}
+			items = append(items, decodeItem(e.text, editDistance, e.valueStart, e.valueEnd))
 		}
-		val, _ := binary.Uvarint(indexData[end-binary.MaxVarintLen64 : end])
-		item := Item{
-			string(indexData[start : end-binary.MaxVarintLen64]),
-			val,
+	} else if search != "" {
+		seen := make(map[int]bool) // offsets already turned into an item
+		offsets := index.Lookup([]byte(search), maxLookup)
+		for _, offset := range offsets {
+			if seen[offset] {
+				continue
+			}
+			if ctx.Err() != nil {
+				return items
+			}
+
+			start, end := offset, offset
+			for ; start > 0 && indexData[start-1] != delimiter; start-- {
+			}
+			for ; end < len(indexData) && indexData[end] != delimiter; end++ {
+			}
+			for ; end+1 < len(indexData) && indexData[end+1] == delimiter; end++ { // consume the rest delimiter bytes
+			}
+			if end-offset < valueSize { // indexed bytes in the value segment
+				continue
+			}
+
+			if mode == modePrefix && start != offset { // only keep matches anchored at the entry's start
+				continue
+			}
+
+			seen[offset] = true
+			entry := string(indexData[start : end-valueSize])
+			items = append(items, decodeItem(entry, 0, end-valueSize, end))
 		}
-		items = append(items, item)
+	}
+
+	if opts.Limit > 0 && len(items) > opts.Limit {
+		items = topKByScore(items, opts.Limit)
+	} else {
+		sortItemsByScoreDesc(items)
 	}
 
 	return items
 }
 
-func startConsole(index *suffixarray.Index) {
+func startConsole(index *suffixarray.Index, codec ValueCodec) {
 	var searchStr string
 	for {
 		fmt.Printf(">> Search for: ")
@@ -139,12 +312,16 @@ func startConsole(index *suffixarray.Index) {
 		}
 
 		t0 := time.Now()
-		items := indexLookup(index, searchStr)
+		items := indexLookup(context.Background(), index, codec, searchStr, lookupOptions{})
 		t1 := time.Now()
 
 		fmt.Printf(">>   %v records found in %v:\n", len(items), t1.Sub(t0))
 		for _, item := range items {
-			fmt.Printf("%v\t%v\n", item.Query, item.Value)
+			if len(item.Fields) > 1 {
+				fmt.Printf("%v\t%v\n", item.Query, item.Fields)
+			} else {
+				fmt.Printf("%v\t%v\n", item.Query, item.Value)
+			}
 		}
 		fmt.Println()
 	}
@@ -154,9 +331,9 @@ func usage() {
 	fmt.Printf(`Usage: pomelo COMMAND [OPTIONS]
 
 Command:
-	-console -index=PATH
-	-web [-index=PATH] [-http=:8080] [-procs=2]
-	-build -src=PATH -dst=PATH [-max-length=120] [-min-value=1000]
+	-console -index=PATH [-decrypt-key=PASSPHRASE] [-schema=uvarint:value]
+	-web [-index=PATH] [-decrypt-key=PASSPHRASE] [-schema=uvarint:value] [-manifest=PATH] [-decrypt-keys=PATH] [-http=:8080] [-procs=2] [-tls-cert=PATH -tls-key=PATH] [-auth-token=TOKEN] [-auth-htpasswd=PATH] [-rate-limit=10] [-rate-burst=20] [-grpc=:9090]
+	-build -src=PATH -dst=PATH [-max-length=120] [-min-value=1000] [-encrypt-key=PASSPHRASE] [-schema=uvarint:value]
 `)
 }
 
@@ -168,28 +345,76 @@ func main() {
 	flag.BoolVar(&cmdWeb, "web", false, "")
 	flag.BoolVar(&cmdBuild, "build", false, "")
 
-	var indexData, indexKey, httpAddr string
+	var indexData, indexKey, httpAddr, decryptKey, manifestPath, decryptKeysPath, schema string
 	var procs int
 	flag.StringVar(&indexData, "index", "", "index data path")
 	flag.StringVar(&indexKey, "key", "", "index key")
 	flag.StringVar(&httpAddr, "http", ":8080", "web server address")
 	flag.IntVar(&procs, "procs", 2, "max process number")
+	flag.StringVar(&decryptKey, "decrypt-key", "", "passphrase to decrypt an index file built with -encrypt-key")
+	flag.StringVar(&manifestPath, "manifest", "pomelo-manifest.json", "path to the index registry manifest file")
+	flag.StringVar(&decryptKeysPath, "decrypt-keys", "", "path to a JSON {indexKey: passphrase} file, used to auto-reload encrypted indexes from the manifest on startup")
+	flag.StringVar(&schema, "schema", "uvarint:value", "value codec and column order used to encode/decode index entries, e.g. fixed64x2:weight,category")
+
+	var tlsCert, tlsKey, authToken, authHtpasswd string
+	var rateLimit float64
+	var rateBurst int
+	flag.StringVar(&tlsCert, "tls-cert", "", "TLS certificate path, serves HTTPS when set with -tls-key")
+	flag.StringVar(&tlsKey, "tls-key", "", "TLS private key path, serves HTTPS when set with -tls-cert")
+	flag.StringVar(&authToken, "auth-token", "", "bearer token required from remote clients on mutation endpoints")
+	flag.StringVar(&authHtpasswd, "auth-htpasswd", "", "htpasswd-style file of user:bcrypt-hash pairs for HTTP Basic auth")
+	flag.Float64Var(&rateLimit, "rate-limit", 10, "max queries per second per remote IP")
+	flag.IntVar(&rateBurst, "rate-burst", 20, "query rate limiter burst size per remote IP")
+
+	var grpcAddr string
+	flag.StringVar(&grpcAddr, "grpc", "", "grpc server address, e.g. :9090 (enables the gRPC API alongside -web)")
 
 	var src, dst string
 	var maxLength, minValue uint
+	var encryptKey string
 	flag.StringVar(&src, "src", "", "input data file path")
 	flag.StringVar(&dst, "dst", "", "output index data file path")
 	flag.UintVar(&maxLength, "max-length", 120, "max length of index entry")
 	flag.UintVar(&minValue, "min-value", 1000, "minimum value of index entry")
+	flag.StringVar(&encryptKey, "encrypt-key", "", "passphrase to encrypt the built index file at rest")
 
 	flag.Usage = func() { usage() }
 	flag.Parse()
 
 	var err error
 	if cmdConsole || cmdWeb {
-		saIndexes = make(map[string]*suffixarray.Index)
+		indexRegistry, err = registry.New(manifestPath, logger)
+		if err != nil {
+			fmt.Printf("Failed to initialize index registry: %v\n", err)
+			os.Exit(1)
+		}
+		defer indexRegistry.Close()
+
+		if cmdWeb {
+			decryptKeys, err := loadDecryptKeys(decryptKeysPath)
+			if err != nil {
+				fmt.Printf("Failed to read -decrypt-keys file %v: %v\n", decryptKeysPath, err)
+				os.Exit(1)
+			}
+
+			if entries, err := registry.LoadManifestEntries(manifestPath); err != nil {
+				fmt.Printf("Failed to read manifest %v: %v\n", manifestPath, err)
+			} else {
+				var failedKeys []string
+				for _, entry := range entries {
+					if _, err := loadIndex(entry.Path, entry.Key, decryptKeys[entry.Key], entry.Schema); err != nil {
+						fmt.Printf("Failed to reload index [%v] from manifest: %v\n", entry.Key, err)
+						failedKeys = append(failedKeys, entry.Key)
+					}
+				}
+				if len(failedKeys) > 0 {
+					fmt.Printf(">> WARNING: %v index(es) were NOT reloaded from the manifest and are unavailable until reloaded manually or their passphrase is added to -decrypt-keys: %v\n", len(failedKeys), failedKeys)
+				}
+			}
+		}
+
 		if indexData != "" {
-			indexKey, err = loadIndex(indexData, indexKey)
+			indexKey, err = loadIndex(indexData, indexKey, decryptKey, schema)
 			if err != nil {
 				fmt.Printf("Load index data from %v failed.\n", indexData)
 				os.Exit(1)
@@ -200,11 +425,42 @@ func main() {
 		}
 
 		if cmdConsole {
-			startConsole(saIndexes[indexKey])
+			index, _ := indexRegistry.Get(indexKey)
+			indexSchema, _ := indexRegistry.Schema(indexKey)
+			codec, err := parseSchema(indexSchema)
+			if err != nil {
+				fmt.Printf("Unknown value codec %v: %v\n", indexSchema, err)
+				os.Exit(1)
+			}
+			startConsole(index, codec)
 		} else if cmdWeb {
 			runtime.GOMAXPROCS(procs)
+			if grpcAddr != "" {
+				go func() {
+					fmt.Printf(">> Running grpc index service on %v ...\n", grpcAddr)
+					grpcCfg := grpcServerConfig{
+						TLSCert:      tlsCert,
+						TLSKey:       tlsKey,
+						AuthToken:    authToken,
+						AuthHtpasswd: authHtpasswd,
+						RateLimit:    rateLimit,
+						RateBurst:    rateBurst,
+					}
+					if err := startGRPCServer(grpcAddr, grpcCfg); err != nil {
+						fmt.Println(err)
+					}
+				}()
+			}
 			fmt.Printf(">> Running index service on %v ...\n", httpAddr)
-			if err := startWebServer(httpAddr); err != nil {
+			webCfg := webServerConfig{
+				TLSCert:      tlsCert,
+				TLSKey:       tlsKey,
+				AuthToken:    authToken,
+				AuthHtpasswd: authHtpasswd,
+				RateLimit:    rateLimit,
+				RateBurst:    rateBurst,
+			}
+			if err := startWebServer(httpAddr, webCfg); err != nil {
 				fmt.Println(err)
 			}
 		}
@@ -215,7 +471,7 @@ func main() {
 		}
 
 		t0 := time.Now()
-		count, err := buildIndex(src, dst, maxLength, minValue)
+		count, err := buildIndex(src, dst, maxLength, minValue, encryptKey, schema)
 		if err != nil {
 			fmt.Printf("Unknown error: %v", err)
 			os.Exit(1)
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIndexLookupFuzzyStopsScanningOnCanceledContext(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.tsv")
+	dst := filepath.Join(dir, "out.idx")
+
+	if err := os.WriteFile(src, []byte("hello\nworld\n"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := buildIndex(src, dst, 120, 0, "", "uvarint:value"); err != nil {
+		t.Fatalf("buildIndex: %v", err)
+	}
+
+	index, err := readIndexFile(dst, "")
+	if err != nil {
+		t.Fatalf("readIndexFile: %v", err)
+	}
+	codec, err := parseSchema("uvarint:value")
+	if err != nil {
+		t.Fatalf("parseSchema: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// parseEntries must honor an already-canceled context itself, not
+	// just the Levenshtein filter loop that runs after it, or a short
+	// deadline can't bound the O(n) fuzzy scan at all.
+	items := indexLookup(ctx, index, codec, "helo", lookupOptions{Mode: modeFuzzy})
+	if len(items) != 0 {
+		t.Fatalf("indexLookup with a canceled context: got %v items, want 0", len(items))
+	}
+}
+
+func TestBuildIndexFiltersByNamedColumnNotPosition(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.tsv")
+	dst := filepath.Join(dir, "out.idx")
+
+	// "id" comes before "weight" in both the schema and the data, so a
+	// positional filter would read "A1"/"A2" as the weight and reject
+	// every row; the filter must find "weight" by name instead.
+	data := "apple\tA1\t500\nbanana\tA2\t9999\n"
+	if err := os.WriteFile(src, []byte(data), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	count, err := buildIndex(src, dst, 120, 1000, "", "json:id,weight")
+	if err != nil {
+		t.Fatalf("buildIndex: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("buildIndex: got %v entries, want 1 (only banana clears -min-value=1000 on weight)", count)
+	}
+}
+
+func TestIndexLookupUsesCodecsNamedValueColumn(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.tsv")
+	dst := filepath.Join(dir, "out.idx")
+
+	if err := os.WriteFile(src, []byte("apple\t5000\n"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := buildIndex(src, dst, 120, 0, "", "uvarint:weight"); err != nil {
+		t.Fatalf("buildIndex: %v", err)
+	}
+
+	index, err := readIndexFile(dst, "")
+	if err != nil {
+		t.Fatalf("readIndexFile: %v", err)
+	}
+	codec, err := parseSchema("uvarint:weight")
+	if err != nil {
+		t.Fatalf("parseSchema: %v", err)
+	}
+
+	items := indexLookup(context.Background(), index, codec, "apple", lookupOptions{})
+	if len(items) != 1 {
+		t.Fatalf("indexLookup: got %v items, want 1", len(items))
+	}
+	if items[0].Value != 5000 {
+		t.Fatalf("indexLookup: got Value=%v, want 5000 (the uvarint:weight column, not the literal \"value\" key)", items[0].Value)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+)
+
+const (
+	modeExact  = "exact"
+	modePrefix = "prefix"
+	modeFuzzy  = "fuzzy"
+
+	// maxFuzzyEdits is the largest Levenshtein distance between the
+	// query and an indexed term that a fuzzy query still returns.
+	maxFuzzyEdits = 2
+
+	scoreExactBase     = 100.0
+	scorePrefixBase    = 70.0
+	scoreFuzzyBase     = 40.0
+	scoreEditPenalty   = 8.0
+	scoreLengthPenalty = 0.05
+)
+
+// lookupOptions controls how indexLookup matches and ranks results.
+// The zero value behaves like the original unrestricted substring
+// lookup: exact matching, no top-K cutoff.
+type lookupOptions struct {
+	Mode  string
+	Limit int
+}
+
+// computeScore ranks a hit by match quality (exact > prefix > fuzzy,
+// closer fuzzy matches score higher than distant ones), the weight
+// stored alongside the entry, and a small penalty for longer entries
+// so that shorter, more specific matches are preferred.
+func computeScore(mode string, editDistance int, value uint64, entryLength int) float64 {
+	base := scoreFuzzyBase
+	switch mode {
+	case modeExact:
+		base = scoreExactBase
+	case modePrefix:
+		base = scorePrefixBase
+	}
+	base -= float64(editDistance) * scoreEditPenalty
+	base += math.Log1p(float64(value))
+	base -= float64(entryLength) * scoreLengthPenalty
+	return base
+}
+
+func sortItemsByScoreDesc(items []Item) {
+	sort.Slice(items, func(i, j int) bool { return items[i].Score > items[j].Score })
+}
+
+// itemHeap is a min-heap on Score, used by topKByScore to keep only the
+// K highest scoring items without sorting the full result set.
+type itemHeap []Item
+
+func (h itemHeap) Len() int            { return len(h) }
+func (h itemHeap) Less(i, j int) bool  { return h[i].Score < h[j].Score }
+func (h itemHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *itemHeap) Push(x interface{}) { *h = append(*h, x.(Item)) }
+func (h *itemHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+func topKByScore(items []Item, k int) []Item {
+	h := &itemHeap{}
+	heap.Init(h)
+	for _, item := range items {
+		if h.Len() < k {
+			heap.Push(h, item)
+			continue
+		}
+		if item.Score > (*h)[0].Score {
+			heap.Pop(h)
+			heap.Push(h, item)
+		}
+	}
+
+	result := make([]Item, h.Len())
+	for i := len(result) - 1; i >= 0; i-- {
+		result[i] = heap.Pop(h).(Item)
+	}
+	return result
+}
+
+// levenshtein returns the classic edit distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	la, lb := len(ra), len(rb)
+	if la == 0 {
+		return lb
+	}
+	if lb == 0 {
+		return la
+	}
+
+	prev := make([]int, lb+1)
+	curr := make([]int, lb+1)
+	for j := 0; j <= lb; j++ {
+		prev[j] = j
+	}
+	for i := 1; i <= la; i++ {
+		curr[0] = i
+		for j := 1; j <= lb; j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[lb]
+}
+
+func min3(a, b, c int) int {
+	m := a
+	if b < m {
+		m = b
+	}
+	if c < m {
+		m = c
+	}
+	return m
+}
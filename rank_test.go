@@ -0,0 +1,73 @@
+package main
+
+import "testing"
+
+func TestLevenshtein(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"hello", "", 5},
+		{"", "hello", 5},
+		{"hello", "hello", 0},
+		{"hello", "helo", 1},  // deletion
+		{"helo", "hello", 1},  // insertion, the reverse direction
+		{"hello", "hallo", 1}, // substitution
+		{"kitten", "sitting", 3},
+	}
+	for _, c := range cases {
+		if got := levenshtein(c.a, c.b); got != c.want {
+			t.Errorf("levenshtein(%q, %q): got %v, want %v", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestTopKByScore(t *testing.T) {
+	items := []Item{
+		{Query: "a", Score: 10},
+		{Query: "b", Score: 50},
+		{Query: "c", Score: 30},
+		{Query: "d", Score: 90},
+		{Query: "e", Score: 20},
+	}
+
+	top := topKByScore(items, 2)
+	if len(top) != 2 {
+		t.Fatalf("topKByScore: got %v items, want 2", len(top))
+	}
+	if top[0].Query != "d" || top[1].Query != "b" {
+		t.Fatalf("topKByScore: got %v, %v, want d then b (descending by score)", top[0].Query, top[1].Query)
+	}
+}
+
+func TestTopKByScoreLimitExceedsLength(t *testing.T) {
+	items := []Item{{Query: "a", Score: 1}, {Query: "b", Score: 2}}
+	top := topKByScore(items, 5)
+	if len(top) != 2 {
+		t.Fatalf("topKByScore with limit > len(items): got %v items, want 2", len(top))
+	}
+}
+
+func TestComputeScoreOrdering(t *testing.T) {
+	exact := computeScore(modeExact, 0, 0, 5)
+	prefix := computeScore(modePrefix, 0, 0, 5)
+	fuzzyClose := computeScore(modeFuzzy, 1, 0, 5)
+	fuzzyFar := computeScore(modeFuzzy, 2, 0, 5)
+
+	if !(exact > prefix && prefix > fuzzyClose) {
+		t.Fatalf("computeScore: expected exact > prefix > fuzzy, got %v, %v, %v", exact, prefix, fuzzyClose)
+	}
+	if !(fuzzyClose > fuzzyFar) {
+		t.Fatalf("computeScore: expected closer fuzzy matches to score higher, got %v <= %v", fuzzyClose, fuzzyFar)
+	}
+}
+
+func TestComputeScoreFavorsHigherValueAndShorterEntry(t *testing.T) {
+	if computeScore(modeExact, 0, 100, 5) <= computeScore(modeExact, 0, 0, 5) {
+		t.Fatalf("computeScore: a higher stored value should not lower the score")
+	}
+	if computeScore(modeExact, 0, 0, 50) >= computeScore(modeExact, 0, 0, 5) {
+		t.Fatalf("computeScore: a longer entry should score lower than a shorter one, all else equal")
+	}
+}
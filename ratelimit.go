@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ipRateLimiter hands out one token-bucket limiter per remote IP so a
+// single abusive client can't starve the query endpoint for everyone
+// else. Idle entries are swept periodically to keep the map bounded.
+type ipRateLimiter struct {
+	mu       sync.Mutex
+	limiters map[string]*rateLimiterEntry
+	rate     rate.Limit
+	burst    int
+}
+
+type rateLimiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newIPRateLimiter(ratePerSecond float64, burst int) *ipRateLimiter {
+	rl := &ipRateLimiter{
+		limiters: make(map[string]*rateLimiterEntry),
+		rate:     rate.Limit(ratePerSecond),
+		burst:    burst,
+	}
+	go rl.cleanup()
+	return rl
+}
+
+func (rl *ipRateLimiter) cleanup() {
+	for range time.Tick(time.Minute) {
+		rl.mu.Lock()
+		for ip, entry := range rl.limiters {
+			if time.Since(entry.lastSeen) > 3*time.Minute {
+				delete(rl.limiters, ip)
+			}
+		}
+		rl.mu.Unlock()
+	}
+}
+
+func (rl *ipRateLimiter) allow(ip string) bool {
+	// A host:port address (e.g. a gRPC peer) shares its host's bucket.
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	rl.mu.Lock()
+	entry, existed := rl.limiters[ip]
+	if !existed {
+		entry = &rateLimiterEntry{limiter: rate.NewLimiter(rl.rate, rl.burst)}
+		rl.limiters[ip] = entry
+	}
+	entry.lastSeen = time.Now()
+	limiter := entry.limiter
+	rl.mu.Unlock()
+
+	return limiter.Allow()
+}
+
+func (rl *ipRateLimiter) wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		if !rl.allow(host) {
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		next(w, r)
+	}
+}
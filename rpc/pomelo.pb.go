@@ -0,0 +1,96 @@
+// Hand-written to mirror protoc-gen-go's output shape; there is no
+// protoc/protoc-gen-go in this project's toolchain to regenerate it.
+// pomelo.proto is still the source of truth for the message shapes -
+// keep this file in sync by hand when it changes.
+// source: pomelo.proto
+
+package rpc
+
+import (
+	"fmt"
+)
+
+type Item struct {
+	Query  string            `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	Value  uint64            `protobuf:"varint,2,opt,name=value,proto3" json:"value,omitempty"`
+	Fields map[string]string `protobuf:"bytes,3,rep,name=fields,proto3" json:"fields,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *Item) Reset()         { *m = Item{} }
+func (m *Item) String() string { return fmt.Sprintf("%+v", *m) }
+func (*Item) ProtoMessage()    {}
+
+type LookupRequest struct {
+	Key   string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Query string `protobuf:"bytes,2,opt,name=query,proto3" json:"query,omitempty"`
+}
+
+func (m *LookupRequest) Reset()         { *m = LookupRequest{} }
+func (m *LookupRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*LookupRequest) ProtoMessage()    {}
+
+type LookupResponse struct {
+	Items []*Item `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+}
+
+func (m *LookupResponse) Reset()         { *m = LookupResponse{} }
+func (m *LookupResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*LookupResponse) ProtoMessage()    {}
+
+type BatchLookupRequest struct {
+	Key     string   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Queries []string `protobuf:"bytes,2,rep,name=queries,proto3" json:"queries,omitempty"`
+}
+
+func (m *BatchLookupRequest) Reset()         { *m = BatchLookupRequest{} }
+func (m *BatchLookupRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*BatchLookupRequest) ProtoMessage()    {}
+
+type LoadIndexRequest struct {
+	Path       string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Key        string `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	DecryptKey string `protobuf:"bytes,3,opt,name=decrypt_key,json=decryptKey,proto3" json:"decrypt_key,omitempty"`
+	Schema     string `protobuf:"bytes,4,opt,name=schema,proto3" json:"schema,omitempty"`
+}
+
+func (m *LoadIndexRequest) Reset()         { *m = LoadIndexRequest{} }
+func (m *LoadIndexRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*LoadIndexRequest) ProtoMessage()    {}
+
+type LoadIndexResponse struct {
+	Key string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+}
+
+func (m *LoadIndexResponse) Reset()         { *m = LoadIndexResponse{} }
+func (m *LoadIndexResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*LoadIndexResponse) ProtoMessage()    {}
+
+type UnloadIndexRequest struct {
+	Key string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+}
+
+func (m *UnloadIndexRequest) Reset()         { *m = UnloadIndexRequest{} }
+func (m *UnloadIndexRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*UnloadIndexRequest) ProtoMessage()    {}
+
+type UnloadIndexResponse struct {
+}
+
+func (m *UnloadIndexResponse) Reset()         { *m = UnloadIndexResponse{} }
+func (m *UnloadIndexResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*UnloadIndexResponse) ProtoMessage()    {}
+
+type ListIndexesRequest struct {
+}
+
+func (m *ListIndexesRequest) Reset()         { *m = ListIndexesRequest{} }
+func (m *ListIndexesRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*ListIndexesRequest) ProtoMessage()    {}
+
+type ListIndexesResponse struct {
+	Keys []string `protobuf:"bytes,1,rep,name=keys,proto3" json:"keys,omitempty"`
+}
+
+func (m *ListIndexesResponse) Reset()         { *m = ListIndexesResponse{} }
+func (m *ListIndexesResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*ListIndexesResponse) ProtoMessage()    {}
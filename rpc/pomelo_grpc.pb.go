@@ -0,0 +1,213 @@
+// Hand-written to mirror protoc-gen-go-grpc's output shape; there is no
+// protoc/protoc-gen-go-grpc in this project's toolchain to regenerate
+// it. pomelo.proto is still the source of truth for the service shape -
+// keep this file (both the server API and the PomeloClient below) in
+// sync by hand when it changes.
+// source: pomelo.proto
+
+package rpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// PomeloClient is the client API for the Pomelo service.
+type PomeloClient interface {
+	Lookup(ctx context.Context, in *LookupRequest, opts ...grpc.CallOption) (*LookupResponse, error)
+	BatchLookup(ctx context.Context, in *BatchLookupRequest, opts ...grpc.CallOption) (Pomelo_BatchLookupClient, error)
+	LoadIndex(ctx context.Context, in *LoadIndexRequest, opts ...grpc.CallOption) (*LoadIndexResponse, error)
+	UnloadIndex(ctx context.Context, in *UnloadIndexRequest, opts ...grpc.CallOption) (*UnloadIndexResponse, error)
+	ListIndexes(ctx context.Context, in *ListIndexesRequest, opts ...grpc.CallOption) (*ListIndexesResponse, error)
+}
+
+type pomeloClient struct {
+	cc grpc.ClientConnInterface
+}
+
+// NewPomeloClient wraps a grpc.ClientConn (or any grpc.ClientConnInterface,
+// e.g. a bufconn-backed one in tests) in a PomeloClient.
+func NewPomeloClient(cc grpc.ClientConnInterface) PomeloClient {
+	return &pomeloClient{cc}
+}
+
+func (c *pomeloClient) Lookup(ctx context.Context, in *LookupRequest, opts ...grpc.CallOption) (*LookupResponse, error) {
+	out := new(LookupResponse)
+	if err := c.cc.Invoke(ctx, "/rpc.Pomelo/Lookup", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pomeloClient) BatchLookup(ctx context.Context, in *BatchLookupRequest, opts ...grpc.CallOption) (Pomelo_BatchLookupClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Pomelo_ServiceDesc.Streams[0], "/rpc.Pomelo/BatchLookup", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &pomeloBatchLookupClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// Pomelo_BatchLookupClient is the client-side stream for the BatchLookup RPC.
+type Pomelo_BatchLookupClient interface {
+	Recv() (*Item, error)
+	grpc.ClientStream
+}
+
+type pomeloBatchLookupClient struct {
+	grpc.ClientStream
+}
+
+func (x *pomeloBatchLookupClient) Recv() (*Item, error) {
+	m := new(Item)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *pomeloClient) LoadIndex(ctx context.Context, in *LoadIndexRequest, opts ...grpc.CallOption) (*LoadIndexResponse, error) {
+	out := new(LoadIndexResponse)
+	if err := c.cc.Invoke(ctx, "/rpc.Pomelo/LoadIndex", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pomeloClient) UnloadIndex(ctx context.Context, in *UnloadIndexRequest, opts ...grpc.CallOption) (*UnloadIndexResponse, error) {
+	out := new(UnloadIndexResponse)
+	if err := c.cc.Invoke(ctx, "/rpc.Pomelo/UnloadIndex", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pomeloClient) ListIndexes(ctx context.Context, in *ListIndexesRequest, opts ...grpc.CallOption) (*ListIndexesResponse, error) {
+	out := new(ListIndexesResponse)
+	if err := c.cc.Invoke(ctx, "/rpc.Pomelo/ListIndexes", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PomeloServer is the server API for the Pomelo service.
+type PomeloServer interface {
+	Lookup(context.Context, *LookupRequest) (*LookupResponse, error)
+	BatchLookup(*BatchLookupRequest, Pomelo_BatchLookupServer) error
+	LoadIndex(context.Context, *LoadIndexRequest) (*LoadIndexResponse, error)
+	UnloadIndex(context.Context, *UnloadIndexRequest) (*UnloadIndexResponse, error)
+	ListIndexes(context.Context, *ListIndexesRequest) (*ListIndexesResponse, error)
+}
+
+// Pomelo_BatchLookupServer is the server-side stream for the BatchLookup RPC.
+type Pomelo_BatchLookupServer interface {
+	Send(*Item) error
+	grpc.ServerStream
+}
+
+type pomeloBatchLookupServer struct {
+	grpc.ServerStream
+}
+
+func (s *pomeloBatchLookupServer) Send(item *Item) error {
+	return s.ServerStream.SendMsg(item)
+}
+
+func _Pomelo_Lookup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LookupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PomeloServer).Lookup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/rpc.Pomelo/Lookup"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PomeloServer).Lookup(ctx, req.(*LookupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Pomelo_BatchLookup_Handler(srv interface{}, stream grpc.ServerStream) error {
+	in := new(BatchLookupRequest)
+	if err := stream.RecvMsg(in); err != nil {
+		return err
+	}
+	return srv.(PomeloServer).BatchLookup(in, &pomeloBatchLookupServer{stream})
+}
+
+func _Pomelo_LoadIndex_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LoadIndexRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PomeloServer).LoadIndex(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/rpc.Pomelo/LoadIndex"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PomeloServer).LoadIndex(ctx, req.(*LoadIndexRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Pomelo_UnloadIndex_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnloadIndexRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PomeloServer).UnloadIndex(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/rpc.Pomelo/UnloadIndex"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PomeloServer).UnloadIndex(ctx, req.(*UnloadIndexRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Pomelo_ListIndexes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListIndexesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PomeloServer).ListIndexes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/rpc.Pomelo/ListIndexes"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PomeloServer).ListIndexes(ctx, req.(*ListIndexesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Pomelo_ServiceDesc is the grpc.ServiceDesc for the Pomelo service.
+var Pomelo_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "rpc.Pomelo",
+	HandlerType: (*PomeloServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Lookup", Handler: _Pomelo_Lookup_Handler},
+		{MethodName: "LoadIndex", Handler: _Pomelo_LoadIndex_Handler},
+		{MethodName: "UnloadIndex", Handler: _Pomelo_UnloadIndex_Handler},
+		{MethodName: "ListIndexes", Handler: _Pomelo_ListIndexes_Handler},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "BatchLookup",
+			Handler:       _Pomelo_BatchLookup_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "pomelo.proto",
+}
+
+func RegisterPomeloServer(s grpc.ServiceRegistrar, srv PomeloServer) {
+	s.RegisterService(&Pomelo_ServiceDesc, srv)
+}
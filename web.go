@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
+	"strconv"
 	"sync"
 	"time"
 
@@ -13,10 +15,16 @@ import (
 	"github.com/gorilla/mux"
 )
 
+const (
+	defaultQueryTimeout = 2 * time.Second
+	maxQueryTimeout     = 10 * time.Second
+)
+
 type rESTfulResponse struct {
-	Status  int
-	Message string
-	Data    interface{}
+	Status    int
+	Message   string
+	Data      interface{}
+	Truncated bool `json:",omitempty"`
 }
 
 func isLocal(addr string) bool {
@@ -32,12 +40,7 @@ func isLocal(addr string) bool {
 func listHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 
-	keys := []string{}
-	for key := range saIndexes {
-		keys = append(keys, key)
-	}
-
-	response := rESTfulResponse{0, "", keys}
+	response := rESTfulResponse{Status: 0, Message: "", Data: indexRegistry.Keys()}
 	jsonBytes, err := json.Marshal(response)
 	if err != nil {
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
@@ -52,12 +55,24 @@ func listHandler(w http.ResponseWriter, r *http.Request) {
 func queryHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 
+	// Captured once so the per-query goroutines below (which may still be
+	// running after this handler returns, per the timeout/truncation
+	// behavior) close over a stable reference instead of re-reading the
+	// package-level indexRegistry after the request has moved on.
+	reg := indexRegistry
+
 	vars := mux.Vars(r)
 	indexKey := vars["key"]
-	if _, existed := saIndexes[indexKey]; indexKey == "" || !existed {
+	if _, existed := reg.Get(indexKey); indexKey == "" || !existed {
 		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
+	schema, _ := reg.Schema(indexKey)
+	codec, err := parseSchema(schema)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
 
 	qs := r.URL.Query()
 	if qs.Get("q") == "" {
@@ -65,17 +80,52 @@ func queryHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	mode := qs.Get("mode")
+	switch mode {
+	case modeExact, modePrefix, modeFuzzy:
+	default:
+		mode = modeExact
+	}
+
+	limit := 0
+	if topk := qs.Get("topk"); topk != "" {
+		limit, _ = strconv.Atoi(topk)
+	} else if l := qs.Get("limit"); l != "" {
+		limit, _ = strconv.Atoi(l)
+	}
+	opts := lookupOptions{Mode: mode, Limit: limit}
+
+	timeout := defaultQueryTimeout
+	if t := qs.Get("timeout"); t != "" {
+		if d, err := time.ParseDuration(t); err == nil && d > 0 {
+			timeout = d
+		}
+	}
+	if timeout > maxQueryTimeout {
+		timeout = maxQueryTimeout
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
 	t0 := time.Now()
 	var group sync.WaitGroup
-	data := make(map[string]uint64)
+	entries := make(map[string]Item)
 	ch := make(chan []Item)
 
 	// 并发查询
 	for i := 0; i < len(qs["q"]); i++ {
 		group.Add(1)
 		go func(key, query string) {
-			ch <- indexLookup(saIndexes[key], query)
-			group.Done()
+			defer group.Done()
+			index, existed := reg.Get(key)
+			if !existed { // unloaded while this request was in flight
+				return
+			}
+			items := indexLookup(ctx, index, codec, query, opts)
+			select {
+			case ch <- items:
+			case <-ctx.Done():
+			}
 		}(indexKey, qs["q"][i])
 	}
 
@@ -85,18 +135,51 @@ func queryHandler(w http.ResponseWriter, r *http.Request) {
 		close(ch)
 	}()
 
-	// 合并数据
-	for items := range ch {
-		for _, item := range items {
-			data[item.Query] = item.Value
+	// 合并数据，按 Query 去重并保留得分最高的命中；客户端断开或超时时提前结束
+	truncated := false
+mergeLoop:
+	for {
+		select {
+		case items, ok := <-ch:
+			if !ok {
+				// lookups that saw the deadline may have returned or dropped partial results
+				truncated = ctx.Err() != nil
+				break mergeLoop
+			}
+			for _, item := range items {
+				if existing, existed := entries[item.Query]; !existed || item.Score > existing.Score {
+					entries[item.Query] = item
+				}
+			}
+		case <-ctx.Done():
+			truncated = true
+			break mergeLoop
 		}
 	}
 
+	data := make([]Item, 0, len(entries))
+	for _, item := range entries {
+		data = append(data, item)
+	}
+	if limit > 0 && len(data) > limit {
+		data = topKByScore(data, limit)
+	} else {
+		sortItemsByScoreDesc(data)
+	}
+
 	t1 := time.Now()
+	status := http.StatusOK
+	message := fmt.Sprintf("%v items in %v", len(data), t1.Sub(t0))
+	if truncated {
+		status = http.StatusGatewayTimeout
+		message = fmt.Sprintf("%v items in %v (deadline exceeded, truncated)", len(data), t1.Sub(t0))
+	}
 	response := rESTfulResponse{
-		0,
-		fmt.Sprintf("%v items in %v", len(data), t1.Sub(t0)),
-		data}
+		Status:    0,
+		Message:   message,
+		Data:      data,
+		Truncated: truncated,
+	}
 
 	jsonBytes, err := json.Marshal(response)
 	if err != nil {
@@ -105,16 +188,13 @@ func queryHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "text/javascript")
+	w.WriteHeader(status)
 	w.Write(jsonBytes)
 	return
 }
 
 func loadHandler(w http.ResponseWriter, r *http.Request) {
-	// 只允许本地加载，避免因网络调用而需要增加的安全性检查
-	if !isLocal(r.RemoteAddr) {
-		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
-		return
-	}
+	// 本地调用直接放行，远程调用的身份校验已由 authGuard 完成
 	if err := r.ParseForm(); err != nil {
 		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
@@ -122,15 +202,17 @@ func loadHandler(w http.ResponseWriter, r *http.Request) {
 
 	indexPath := r.PostForm.Get("path")
 	indexKey := r.PostForm.Get("key")
-	key, err := loadIndex(indexPath, indexKey)
+	decryptKey := r.PostForm.Get("decrypt-key")
+	schema := r.PostForm.Get("schema")
+	key, err := loadIndex(indexPath, indexKey, decryptKey, schema)
 	if err != nil {
 		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
 
 	response := rESTfulResponse{
-		0,
-		fmt.Sprintf("Index [%v] loaded", key), nil}
+		Status:  0,
+		Message: fmt.Sprintf("Index [%v] loaded", key)}
 
 	jsonBytes, err := json.Marshal(response)
 	if err != nil {
@@ -144,23 +226,42 @@ func loadHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func unloadHandler(w http.ResponseWriter, r *http.Request) {
-	if !isLocal(r.RemoteAddr) {
-		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	// 本地调用直接放行，远程调用的身份校验已由 authGuard 完成
+	vars := mux.Vars(r)
+	indexKey := vars["key"]
+	if _, existed := indexRegistry.Get(indexKey); indexKey == "" || !existed {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	indexRegistry.Delete(indexKey)
+
+	response := rESTfulResponse{
+		Status:  0,
+		Message: fmt.Sprintf("Index [%v] deleted", indexKey)}
+
+	jsonBytes, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Set("Content-Type", "text/javascript")
+	w.Write(jsonBytes)
+	return
+}
+
+func reloadHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	indexKey := vars["key"]
-	if _, existed := saIndexes[indexKey]; indexKey == "" || !existed {
+	if err := indexRegistry.Reload(indexKey); err != nil {
 		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
 
-	delete(saIndexes, indexKey)
-
 	response := rESTfulResponse{
-		0,
-		fmt.Sprintf("Index [%v] deleted", indexKey), nil}
+		Status:  0,
+		Message: fmt.Sprintf("Index [%v] reloaded", indexKey)}
 
 	jsonBytes, err := json.Marshal(response)
 	if err != nil {
@@ -173,14 +274,37 @@ func unloadHandler(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
-func startWebServer(address string) error {
+// webServerConfig bundles the optional transport and access-control
+// settings for startWebServer so the mutation endpoints (load/unload) can
+// be safely exposed to remote clients instead of only loopback ones.
+type webServerConfig struct {
+	TLSCert      string
+	TLSKey       string
+	AuthToken    string
+	AuthHtpasswd string
+	RateLimit    float64
+	RateBurst    int
+}
+
+func startWebServer(address string, cfg webServerConfig) error {
+	guard, err := newAuthGuard(cfg.AuthToken, cfg.AuthHtpasswd)
+	if err != nil {
+		return err
+	}
+	limiter := newIPRateLimiter(cfg.RateLimit, cfg.RateBurst)
+
 	router := mux.NewRouter()
 	router.HandleFunc("/indexes/", listHandler).Methods("GET")
-	router.HandleFunc("/index/{key:[0-9a-zA-Z]+}/", queryHandler).Methods("GET")
-	router.HandleFunc("/index/", loadHandler).Methods("POST")
-	router.HandleFunc("/index/{key:[0-9a-zA-Z]+}/", unloadHandler).Methods("DELETE")
+	router.HandleFunc("/index/{key:[0-9a-zA-Z]+}/", limiter.wrap(queryHandler)).Methods("GET")
+	router.HandleFunc("/index/", guard.wrap(loadHandler)).Methods("POST")
+	router.HandleFunc("/index/{key:[0-9a-zA-Z]+}/", guard.wrap(unloadHandler)).Methods("DELETE")
+	router.HandleFunc("/index/{key:[0-9a-zA-Z]+}/reload", guard.wrap(reloadHandler)).Methods("POST")
 
 	loggedRouter := handlers.CombinedLoggingHandler(os.Stdout, router)
 	http.Handle("/", loggedRouter)
+
+	if cfg.TLSCert != "" || cfg.TLSKey != "" {
+		return http.ListenAndServeTLS(address, cfg.TLSCert, cfg.TLSKey, nil)
+	}
 	return http.ListenAndServe(address, nil)
 }
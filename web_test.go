@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codeb2cc/pomelo/core/registry"
+	"github.com/gorilla/mux"
+)
+
+// withTestRegistry points the package-level indexRegistry at a fresh
+// Registry backed by a temp manifest, loads one small index under key,
+// and restores the previous registry when the test ends.
+func withTestRegistry(t *testing.T, docs, schema string) string {
+	t.Helper()
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.tsv")
+	dst := filepath.Join(dir, "out.idx")
+	if err := os.WriteFile(src, []byte(docs), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := buildIndex(src, dst, 120, 0, "", schema); err != nil {
+		t.Fatalf("buildIndex: %v", err)
+	}
+
+	previous := indexRegistry
+	reg, err := registry.New(filepath.Join(dir, "manifest.json"), log.New(os.Stderr, "", 0))
+	if err != nil {
+		t.Fatalf("registry.New: %v", err)
+	}
+	t.Cleanup(func() {
+		reg.Close()
+		indexRegistry = previous
+	})
+	indexRegistry = reg
+
+	key, err := loadIndex(dst, "", "", schema)
+	if err != nil {
+		t.Fatalf("loadIndex: %v", err)
+	}
+	return key
+}
+
+func decodeRESTfulResponse(t *testing.T, w *httptest.ResponseRecorder) rESTfulResponse {
+	t.Helper()
+	var resp rESTfulResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response body %q: %v", w.Body.String(), err)
+	}
+	return resp
+}
+
+func TestQueryHandlerReturnsMatches(t *testing.T) {
+	key := withTestRegistry(t, "hello\t5000\nworld\t100\n", "uvarint:value")
+
+	req := httptest.NewRequest(http.MethodGet, "/index/"+key+"/?q=hello", nil)
+	req = mux.SetURLVars(req, map[string]string{"key": key})
+	w := httptest.NewRecorder()
+
+	queryHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("queryHandler: got status %v, want 200", w.Code)
+	}
+	resp := decodeRESTfulResponse(t, w)
+	if resp.Truncated {
+		t.Fatalf("queryHandler: got Truncated=true, want false")
+	}
+	items, ok := resp.Data.([]interface{})
+	if !ok || len(items) != 1 {
+		t.Fatalf("queryHandler: got Data=%v, want exactly one match for \"hello\"", resp.Data)
+	}
+}
+
+func TestQueryHandlerUnknownKeyReturnsBadRequest(t *testing.T) {
+	withTestRegistry(t, "hello\t5000\n", "uvarint:value")
+
+	req := httptest.NewRequest(http.MethodGet, "/index/does-not-exist/?q=hello", nil)
+	req = mux.SetURLVars(req, map[string]string{"key": "does-not-exist"})
+	w := httptest.NewRecorder()
+
+	queryHandler(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("queryHandler for an unknown key: got status %v, want 400", w.Code)
+	}
+}
+
+func TestQueryHandlerTruncatesOnExpiredDeadline(t *testing.T) {
+	key := withTestRegistry(t, "hello\t5000\nworld\t100\n", "uvarint:value")
+
+	// An already-expired deadline must short-circuit mergeLoop via
+	// ctx.Done() instead of waiting for indexLookup to finish, and the
+	// response must say so via Truncated/504 rather than silently
+	// returning as if nothing were cut off.
+	req := httptest.NewRequest(http.MethodGet, "/index/"+key+"/?q=hello&timeout=1ns", nil)
+	req = mux.SetURLVars(req, map[string]string{"key": key})
+	w := httptest.NewRecorder()
+
+	queryHandler(w, req)
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("queryHandler with an expired deadline: got status %v, want 504", w.Code)
+	}
+	resp := decodeRESTfulResponse(t, w)
+	if !resp.Truncated {
+		t.Fatalf("queryHandler with an expired deadline: got Truncated=false, want true")
+	}
+}